@@ -0,0 +1,55 @@
+// Package cluster turns the primitives store and engine already expose into
+// the cluster-wide, multi-node views rpc hands back to clients: live pod
+// topology, and per-container stats/events multiplexed across every node
+// that hosts them
+package cluster
+
+import (
+	"errors"
+	"time"
+
+	etcdstore "gitlab.ricebook.net/platform/core/store/etcd"
+	"gitlab.ricebook.net/platform/core/types"
+	"golang.org/x/net/context"
+)
+
+var errPodnameRequired = errors.New("cluster: podname is required")
+
+// defaultStatsSampleInterval is used when New is given a zero interval; it
+// matches engineHealthInterval's cadence in store/etcd's health ticker
+const defaultStatsSampleInterval = 5 * time.Second
+
+// Store is the subset of store/etcd's krypton that Cluster depends on, kept
+// narrow so Cluster can be driven against a fake in tests
+type Store interface {
+	GetAllNodes() ([]*types.Node, error)
+	WatchPod(ctx context.Context, podname string) <-chan etcdstore.NodeEvent
+}
+
+// Cluster wires store and engine together into the aggregate operations rpc
+// calls; it holds no state of its own beyond the Store and stats sample
+// interval it was built with
+type Cluster struct {
+	store               Store
+	statsSampleInterval time.Duration
+}
+
+// New wires up a Cluster backed by store. statsSampleInterval bounds how
+// often ContainerStats forwards a sample per container; a zero value falls
+// back to defaultStatsSampleInterval
+func New(store Store, statsSampleInterval time.Duration) *Cluster {
+	if statsSampleInterval <= 0 {
+		statsSampleInterval = defaultStatsSampleInterval
+	}
+	return &Cluster{store: store, statsSampleInterval: statsSampleInterval}
+}
+
+// WatchPodNodes streams podname's node topology changes for as long as ctx
+// stays alive; the fan-out already happens one level down, in store's etcd
+// watch, so this just validates input and hands the channel through
+func (c *Cluster) WatchPodNodes(ctx context.Context, podname string) (<-chan etcdstore.NodeEvent, error) {
+	if podname == "" {
+		return nil, errPodnameRequired
+	}
+	return c.store.WatchPod(ctx, podname), nil
+}
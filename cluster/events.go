@@ -0,0 +1,111 @@
+package cluster
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	coretypes "github.com/projecteru2/core/types"
+	"gitlab.ricebook.net/platform/core/engine"
+	enginetypes "gitlab.ricebook.net/platform/core/engine/types"
+	"golang.org/x/net/context"
+)
+
+// dockerEvent mirrors just the fields of docker's raw /events JSON that
+// ContainerEvents needs
+type dockerEvent struct {
+	Type   string `json:"Type"`
+	Action string `json:"Action"`
+	ID     string `json:"id"`
+	Time   int64  `json:"time"`
+	Actor  struct {
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+}
+
+// ContainerEvents streams lifecycle events matching filter from every node
+// in the cluster, merged into a single channel the same way ContainerStats
+// is: one goroutine per node's raw event stream, fanned into a shared output
+// so a quiet node never blocks a busy one. Events from different nodes are
+// interleaved in arrival order, each tagged with its node's name
+func (c *Cluster) ContainerEvents(ctx context.Context, filter coretypes.EventFilter) (<-chan *coretypes.EventMessage, error) {
+	nodes, err := c.store.GetAllNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan *coretypes.EventMessage)
+	var wg sync.WaitGroup
+	for _, node := range nodes {
+		wg.Add(1)
+		go func(nodename string, backend engine.Backend) {
+			defer wg.Done()
+			c.streamEvents(ctx, nodename, backend, filter, out)
+		}(node.Name, node.Engine)
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out, nil
+}
+
+// streamEvents decodes nodename's raw, newline-delimited events JSON,
+// applies filter client-side (on top of whatever the engine already
+// filtered server-side) and forwards matches until ctx is done
+func (c *Cluster) streamEvents(ctx context.Context, nodename string, backend engine.Backend, filter coretypes.EventFilter, out chan<- *coretypes.EventMessage) {
+	body, err := backend.ContainerEvents(ctx, enginetypes.EventsOptions{
+		Type:  filter.Type,
+		Event: filter.Event,
+	})
+	if err != nil {
+		log.Errorf("[streamEvents] events on %q failed: %v", nodename, err)
+		return
+	}
+	defer body.Close()
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		var raw dockerEvent
+		if err := json.Unmarshal(scanner.Bytes(), &raw); err != nil {
+			log.Errorf("[streamEvents] decode event on %q failed: %v", nodename, err)
+			continue
+		}
+
+		if !matchesLabels(raw.Actor.Attributes, filter.Labels) {
+			continue
+		}
+
+		msg := &coretypes.EventMessage{
+			Type:     raw.Type,
+			Action:   raw.Action,
+			ID:       raw.ID,
+			Nodename: nodename,
+			Time:     raw.Time,
+			Labels:   raw.Actor.Attributes,
+		}
+
+		select {
+		case out <- msg:
+		case <-ctx.Done():
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil && err != io.EOF {
+		log.Errorf("[streamEvents] read events on %q failed: %v", nodename, err)
+	}
+}
+
+// matchesLabels reports whether attrs carries every key/value pair in want
+func matchesLabels(attrs, want map[string]string) bool {
+	for k, v := range want {
+		if attrs[k] != v {
+			return false
+		}
+	}
+	return true
+}
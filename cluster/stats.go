@@ -0,0 +1,184 @@
+package cluster
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	coretypes "github.com/projecteru2/core/types"
+	"gitlab.ricebook.net/platform/core/engine"
+	enginetypes "gitlab.ricebook.net/platform/core/engine/types"
+	"golang.org/x/net/context"
+)
+
+// dockerStats mirrors just the fields of docker's raw stats JSON that
+// ContainerStats needs, so it doesn't have to pull in engine-api's full
+// types.Stats (and, by extension, a docker dependency) just to read them
+type dockerStats struct {
+	CPUStats struct {
+		CPUUsage struct {
+			TotalUsage uint64 `json:"total_usage"`
+		} `json:"cpu_usage"`
+		SystemUsage uint64 `json:"system_cpu_usage"`
+		OnlineCPUs  uint32 `json:"online_cpus"`
+	} `json:"cpu_stats"`
+	PreCPUStats struct {
+		CPUUsage struct {
+			TotalUsage uint64 `json:"total_usage"`
+		} `json:"cpu_usage"`
+		SystemUsage uint64 `json:"system_cpu_usage"`
+	} `json:"precpu_stats"`
+	MemoryStats struct {
+		Usage uint64 `json:"usage"`
+		Limit uint64 `json:"limit"`
+	} `json:"memory_stats"`
+	Networks map[string]struct {
+		RxBytes uint64 `json:"rx_bytes"`
+		TxBytes uint64 `json:"tx_bytes"`
+	} `json:"networks"`
+	BlkioStats struct {
+		IOServiceBytesRecursive []struct {
+			Op    string `json:"op"`
+			Value uint64 `json:"value"`
+		} `json:"io_service_bytes_recursive"`
+	} `json:"blkio_stats"`
+	PidsStats struct {
+		Current int `json:"current"`
+	} `json:"pids_stats"`
+}
+
+func (s *dockerStats) cpuPercent() float64 {
+	cpuDelta := float64(s.CPUStats.CPUUsage.TotalUsage) - float64(s.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(s.CPUStats.SystemUsage) - float64(s.PreCPUStats.SystemUsage)
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+	online := float64(s.CPUStats.OnlineCPUs)
+	if online == 0 {
+		online = 1
+	}
+	return (cpuDelta / systemDelta) * online * 100.0
+}
+
+func (s *dockerStats) networkTotals() (rx, tx uint64) {
+	for _, n := range s.Networks {
+		rx += n.RxBytes
+		tx += n.TxBytes
+	}
+	return
+}
+
+func (s *dockerStats) blockTotals() (read, write uint64) {
+	for _, e := range s.BlkioStats.IOServiceBytesRecursive {
+		switch e.Op {
+		case "Read":
+			read += e.Value
+		case "Write":
+			write += e.Value
+		}
+	}
+	return
+}
+
+// ContainerStats streams resource-usage samples for each of ids, at most one
+// per container per statsSampleInterval. It resolves which node hosts each
+// ID by listing every node's containers once up front, then fans each
+// node's raw stats stream into one channel so a slow node can't starve a
+// fast one; samples from different nodes are interleaved in arrival order,
+// each tagged with its node's name, and no ordering across nodes is imposed
+func (c *Cluster) ContainerStats(ctx context.Context, ids []string) (<-chan *coretypes.StatsMessage, error) {
+	want := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		want[id] = struct{}{}
+	}
+
+	nodes, err := c.store.GetAllNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan *coretypes.StatsMessage)
+	var wg sync.WaitGroup
+	for _, node := range nodes {
+		containers, err := node.Engine.ContainerList(ctx, enginetypes.ContainerListOptions{All: true})
+		if err != nil {
+			log.Errorf("[ContainerStats] list containers on %q failed: %v", node.Name, err)
+			continue
+		}
+		for _, ctr := range containers {
+			if _, ok := want[ctr.ID]; !ok {
+				continue
+			}
+			wg.Add(1)
+			go func(nodename string, backend engine.Backend, id string) {
+				defer wg.Done()
+				c.streamStats(ctx, nodename, backend, id, out)
+			}(node.Name, node.Engine, ctr.ID)
+		}
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out, nil
+}
+
+// streamStats decodes nodename's raw, newline-delimited stats JSON for id
+// and forwards each sample until ctx is done or the engine closes the
+// stream; it's the per-container leaf that ContainerStats fans in from
+func (c *Cluster) streamStats(ctx context.Context, nodename string, backend engine.Backend, id string, out chan<- *coretypes.StatsMessage) {
+	body, err := backend.ContainerStats(ctx, id)
+	if err != nil {
+		log.Errorf("[streamStats] stats for %q on %q failed: %v", id, nodename, err)
+		return
+	}
+	defer body.Close()
+
+	var lastSent time.Time
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		var raw dockerStats
+		if err := json.Unmarshal(scanner.Bytes(), &raw); err != nil {
+			log.Errorf("[streamStats] decode stats for %q on %q failed: %v", id, nodename, err)
+			continue
+		}
+
+		// the engine emits a line roughly once a second regardless of what
+		// anyone asked for; drop samples that arrive before the configured
+		// interval has elapsed instead of forwarding every one of them
+		now := time.Now()
+		if !lastSent.IsZero() && now.Sub(lastSent) < c.statsSampleInterval {
+			continue
+		}
+		lastSent = now
+
+		rx, tx := raw.networkTotals()
+		blkRead, blkWrite := raw.blockTotals()
+		msg := &coretypes.StatsMessage{
+			ID:          id,
+			Nodename:    nodename,
+			CPUPercent:  raw.cpuPercent(),
+			MemoryUsage: raw.MemoryStats.Usage,
+			MemoryLimit: raw.MemoryStats.Limit,
+			NetworkRx:   rx,
+			NetworkTx:   tx,
+			BlockRead:   blkRead,
+			BlockWrite:  blkWrite,
+			PidsCurrent: int64(raw.PidsStats.Current),
+		}
+
+		select {
+		case out <- msg:
+		case <-ctx.Done():
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil && err != io.EOF {
+		log.Errorf("[streamStats] read stats for %q on %q failed: %v", id, nodename, err)
+	}
+}
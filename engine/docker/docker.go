@@ -0,0 +1,255 @@
+package docker
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	engineapi "github.com/docker/engine-api/client"
+	dockertypes "github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/container"
+	"github.com/docker/engine-api/types/filters"
+	"github.com/docker/go-connections/tlsconfig"
+	"golang.org/x/net/context"
+
+	"gitlab.ricebook.net/platform/core/engine"
+	"gitlab.ricebook.net/platform/core/engine/types"
+)
+
+func init() {
+	engine.Register("tcp", New)
+	engine.Register("unix", New)
+}
+
+// Backend wraps a real docker/engine-api client and adapts its calls to the
+// engine.Backend interface
+type Backend struct {
+	cli *engineapi.Client
+}
+
+// New dials endpoint with engine-api, optionally over TLS if config.CertPath
+// is set, and returns it wrapped as an engine.Backend
+func New(endpoint string, config engine.Config) (engine.Backend, error) {
+	var httpClient *http.Client
+	if config.CertPath != "" {
+		options := tlsconfig.Options{
+			CAFile:             filepath.Join(config.CertPath, "ca.pem"),
+			CertFile:           filepath.Join(config.CertPath, "cert.pem"),
+			KeyFile:            filepath.Join(config.CertPath, "key.pem"),
+			InsecureSkipVerify: false,
+		}
+		tlsc, err := tlsconfig.Client(options)
+		if err != nil {
+			return nil, err
+		}
+
+		httpClient = &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: tlsc,
+			},
+		}
+	}
+
+	cli, err := engineapi.NewClient(endpoint, config.APIVersion, httpClient, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Backend{cli: cli}, nil
+}
+
+// Info implements engine.Backend
+func (b *Backend) Info(ctx context.Context) (types.Info, error) {
+	info, err := b.cli.Info(ctx)
+	if err != nil {
+		return types.Info{}, err
+	}
+	return types.Info{
+		ID:            info.ID,
+		Driver:        info.Driver,
+		NCPU:          info.NCPU,
+		MemTotal:      info.MemTotal,
+		ServerVersion: info.ServerVersion,
+	}, nil
+}
+
+// ContainerCreate implements engine.Backend
+func (b *Backend) ContainerCreate(ctx context.Context, opts types.ContainerCreateOptions) (types.ContainerCreateResult, error) {
+	config := &container.Config{
+		Image:  opts.Image,
+		Cmd:    opts.Cmd,
+		Env:    opts.Env,
+		Labels: opts.Labels,
+	}
+	hostConfig := &container.HostConfig{
+		Privileged: opts.Privileged,
+		Binds:      opts.Volumes,
+		Resources: container.Resources{
+			Memory:   opts.Memory,
+			CPUQuota: opts.CPUQuota,
+		},
+	}
+
+	body, err := b.cli.ContainerCreate(ctx, config, hostConfig, nil, opts.Name)
+	if err != nil {
+		return types.ContainerCreateResult{}, err
+	}
+	return types.ContainerCreateResult{ID: body.ID, Warnings: body.Warnings}, nil
+}
+
+// ContainerStart implements engine.Backend
+func (b *Backend) ContainerStart(ctx context.Context, ID string) error {
+	return b.cli.ContainerStart(ctx, ID, dockertypes.ContainerStartOptions{})
+}
+
+// ContainerStop implements engine.Backend
+func (b *Backend) ContainerStop(ctx context.Context, ID string, timeout time.Duration) error {
+	return b.cli.ContainerStop(ctx, ID, &timeout)
+}
+
+// ContainerRemove implements engine.Backend
+func (b *Backend) ContainerRemove(ctx context.Context, ID string, force bool) error {
+	return b.cli.ContainerRemove(ctx, ID, dockertypes.ContainerRemoveOptions{Force: force})
+}
+
+// ContainerInspect implements engine.Backend
+func (b *Backend) ContainerInspect(ctx context.Context, ID string) (types.ContainerJSON, error) {
+	info, err := b.cli.ContainerInspect(ctx, ID)
+	if err != nil {
+		return types.ContainerJSON{}, err
+	}
+
+	networks := map[string]string{}
+	if info.NetworkSettings != nil {
+		for name, network := range info.NetworkSettings.Networks {
+			networks[name] = network.IPAddress
+		}
+	}
+
+	return types.ContainerJSON{
+		ID:       info.ID,
+		Image:    info.Image,
+		Running:  info.State != nil && info.State.Running,
+		Networks: networks,
+		Labels:   info.Config.Labels,
+	}, nil
+}
+
+// ContainerList implements engine.Backend
+func (b *Backend) ContainerList(ctx context.Context, opts types.ContainerListOptions) ([]types.Container, error) {
+	containers, err := b.cli.ContainerList(ctx, dockertypes.ContainerListOptions{All: opts.All})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.Container, 0, len(containers))
+	for _, c := range containers {
+		result = append(result, types.Container{
+			ID:     c.ID,
+			Names:  c.Names,
+			Image:  c.Image,
+			Labels: c.Labels,
+		})
+	}
+	return result, nil
+}
+
+// ImagePull implements engine.Backend
+func (b *Backend) ImagePull(ctx context.Context, image string) (io.ReadCloser, error) {
+	return b.cli.ImagePull(ctx, image, dockertypes.ImagePullOptions{})
+}
+
+// ImageBuild implements engine.Backend
+func (b *Backend) ImageBuild(ctx context.Context, tar io.Reader, name string) (io.ReadCloser, error) {
+	resp, err := b.cli.ImageBuild(ctx, tar, dockertypes.ImageBuildOptions{Tags: []string{name}})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// ContainerLogs implements engine.Backend
+func (b *Backend) ContainerLogs(ctx context.Context, ID string, opts types.LogsOptions) (io.ReadCloser, error) {
+	return b.cli.ContainerLogs(ctx, ID, dockertypes.ContainerLogsOptions{
+		Follow:     opts.Follow,
+		Since:      opts.Since,
+		Tail:       opts.Tail,
+		ShowStdout: opts.ShowStdout,
+		ShowStderr: opts.ShowStderr,
+	})
+}
+
+// ContainerStats implements engine.Backend
+func (b *Backend) ContainerStats(ctx context.Context, ID string) (io.ReadCloser, error) {
+	resp, err := b.cli.ContainerStats(ctx, ID, true)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// ContainerEvents implements engine.Backend
+func (b *Backend) ContainerEvents(ctx context.Context, opts types.EventsOptions) (io.ReadCloser, error) {
+	args := filters.NewArgs()
+	if opts.Type != "" {
+		args.Add("type", opts.Type)
+	}
+	if opts.Event != "" {
+		args.Add("event", opts.Event)
+	}
+	for k, v := range opts.Labels {
+		args.Add("label", k+"="+v)
+	}
+
+	resp, err := b.cli.Events(ctx, dockertypes.EventsOptions{
+		Since:   opts.Since,
+		Until:   opts.Until,
+		Filters: args,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Exec implements engine.Backend
+func (b *Backend) Exec(ctx context.Context, ID string, opts types.ExecConfig) (types.ExecResult, error) {
+	created, err := b.cli.ContainerExecCreate(ctx, ID, dockertypes.ExecConfig{
+		Cmd:          opts.Cmd,
+		Env:          opts.Env,
+		Privileged:   opts.Privileged,
+		AttachStdin:  opts.AttachStdin,
+		AttachStdout: opts.AttachStdout,
+		AttachStderr: opts.AttachStderr,
+	})
+	if err != nil {
+		return types.ExecResult{}, err
+	}
+
+	conn, err := b.cli.ContainerExecAttach(ctx, created.ID, dockertypes.ExecConfig{})
+	if err != nil {
+		return types.ExecResult{}, err
+	}
+
+	return types.ExecResult{ExecID: created.ID, Conn: conn.Conn}, nil
+}
+
+// CopyToContainer implements engine.Backend
+func (b *Backend) CopyToContainer(ctx context.Context, ID, path string, content io.Reader) error {
+	return b.cli.CopyToContainer(ctx, ID, path, content, dockertypes.CopyToContainerOptions{})
+}
+
+// CopyFromContainer implements engine.Backend
+func (b *Backend) CopyFromContainer(ctx context.Context, ID, path string) (io.ReadCloser, string, error) {
+	rc, stat, err := b.cli.CopyFromContainer(ctx, ID, path)
+	if err != nil {
+		return nil, "", err
+	}
+	return rc, stat.Name, nil
+}
+
+// Close implements engine.Backend
+func (b *Backend) Close() error {
+	return b.cli.Close()
+}
@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"gitlab.ricebook.net/platform/core/engine/types"
+)
+
+// Backend is the contract every container engine implementation must
+// satisfy. krypton talks to nodes exclusively through this interface so that
+// the scheduler, RPC layer and cluster code never need to know whether a
+// given node is running dockerd, a Podman REST service, or anything else
+// that shows up later
+type Backend interface {
+	Info(ctx context.Context) (types.Info, error)
+
+	ContainerCreate(ctx context.Context, opts types.ContainerCreateOptions) (types.ContainerCreateResult, error)
+	ContainerStart(ctx context.Context, ID string) error
+	ContainerStop(ctx context.Context, ID string, timeout time.Duration) error
+	ContainerRemove(ctx context.Context, ID string, force bool) error
+	ContainerInspect(ctx context.Context, ID string) (types.ContainerJSON, error)
+	ContainerList(ctx context.Context, opts types.ContainerListOptions) ([]types.Container, error)
+
+	ImagePull(ctx context.Context, image string) (io.ReadCloser, error)
+	ImageBuild(ctx context.Context, tar io.Reader, name string) (io.ReadCloser, error)
+
+	ContainerLogs(ctx context.Context, ID string, opts types.LogsOptions) (io.ReadCloser, error)
+	ContainerStats(ctx context.Context, ID string) (io.ReadCloser, error)
+	ContainerEvents(ctx context.Context, opts types.EventsOptions) (io.ReadCloser, error)
+	Exec(ctx context.Context, ID string, opts types.ExecConfig) (types.ExecResult, error)
+
+	CopyToContainer(ctx context.Context, ID, path string, content io.Reader) error
+	CopyFromContainer(ctx context.Context, ID, path string) (io.ReadCloser, string, error)
+
+	// Close tears down whatever transport the backend holds open, e.g. an
+	// HTTP client's idle connections
+	Close() error
+}
+
+// Config carries the bits every backend may need to dial its endpoint.
+// Individual backends ignore whatever fields don't apply to them
+type Config struct {
+	CertPath   string
+	APIVersion string
+}
+
+// Factory dials endpoint and returns a ready-to-use Backend
+type Factory func(endpoint string, config Config) (Backend, error)
+
+var (
+	registryLock sync.RWMutex
+	registry     = map[string]Factory{}
+)
+
+// Register makes a backend factory available under scheme, the URL scheme
+// prefix of node endpoints it should handle, e.g. "tcp", "unix" or
+// "podman+unix". Backends register themselves from an init() function in
+// their own package
+func Register(scheme string, factory Factory) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	registry[scheme] = factory
+}
+
+// Open parses endpoint's scheme and dials the matching registered backend
+func Open(endpoint string, config Config) (Backend, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	registryLock.RLock()
+	factory, ok := registry[u.Scheme]
+	registryLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("No engine backend registered for scheme %q in endpoint %q", u.Scheme, endpoint)
+	}
+
+	return factory(endpoint, config)
+}
+
+// Supports reports whether endpoint's scheme has a registered backend,
+// used by AddNode to validate input before it ever tries to dial anything
+func Supports(endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return false
+	}
+
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	_, ok := registry[u.Scheme]
+	return ok
+}
@@ -0,0 +1,407 @@
+package podman
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"gitlab.ricebook.net/platform/core/engine"
+	"gitlab.ricebook.net/platform/core/engine/types"
+)
+
+func init() {
+	engine.Register("podman+unix", New)
+}
+
+// apiVersion is the docker-compat API version Podman's REST service speaks.
+// Podman keeps this in lockstep with a recent docker engine-api release, so
+// a single constant is enough instead of threading config.APIVersion through
+const apiVersion = "v1.40"
+
+// Backend talks to a Podman service over its docker-compatible REST API,
+// reached over a unix socket as `podman+unix:///run/podman/podman.sock`
+type Backend struct {
+	httpClient *http.Client
+	sockPath   string
+	base       string
+}
+
+// New dials the Podman compat socket named by endpoint, e.g.
+// "podman+unix:///run/podman/podman.sock"
+func New(endpoint string, config engine.Config) (engine.Backend, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "podman+unix" {
+		return nil, fmt.Errorf("Podman backend only supports podman+unix:// endpoints, got %q", endpoint)
+	}
+
+	sockPath := u.Path
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", sockPath)
+			},
+		},
+	}
+
+	return &Backend{
+		httpClient: httpClient,
+		sockPath:   sockPath,
+		base:       "http://podman/" + apiVersion,
+	}, nil
+}
+
+func (b *Backend) do(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, b.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Podman API %s %s: %s: %s", method, path, resp.Status, string(msg))
+	}
+	return resp, nil
+}
+
+func (b *Backend) doJSON(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		bs, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(bs)
+	}
+
+	resp, err := b.do(ctx, method, path, reqBody)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Info implements engine.Backend
+func (b *Backend) Info(ctx context.Context) (types.Info, error) {
+	var raw struct {
+		ID            string `json:"ID"`
+		Driver        string `json:"Driver"`
+		NCPU          int    `json:"NCPU"`
+		MemTotal      int64  `json:"MemTotal"`
+		ServerVersion string `json:"ServerVersion"`
+	}
+	if err := b.doJSON(ctx, http.MethodGet, "/info", nil, &raw); err != nil {
+		return types.Info{}, err
+	}
+	return types.Info{
+		ID:            raw.ID,
+		Driver:        raw.Driver,
+		NCPU:          raw.NCPU,
+		MemTotal:      raw.MemTotal,
+		ServerVersion: raw.ServerVersion,
+	}, nil
+}
+
+// ContainerCreate implements engine.Backend
+func (b *Backend) ContainerCreate(ctx context.Context, opts types.ContainerCreateOptions) (types.ContainerCreateResult, error) {
+	payload := map[string]interface{}{
+		"Image":      opts.Image,
+		"Cmd":        opts.Cmd,
+		"Env":        opts.Env,
+		"Labels":     opts.Labels,
+		"Privileged": opts.Privileged,
+		"HostConfig": map[string]interface{}{
+			"Binds":    opts.Volumes,
+			"Memory":   opts.Memory,
+			"CPUQuota": opts.CPUQuota,
+		},
+	}
+
+	var result struct {
+		ID       string   `json:"Id"`
+		Warnings []string `json:"Warnings"`
+	}
+	path := "/containers/create"
+	if opts.Name != "" {
+		path += "?name=" + url.QueryEscape(opts.Name)
+	}
+	if err := b.doJSON(ctx, http.MethodPost, path, payload, &result); err != nil {
+		return types.ContainerCreateResult{}, err
+	}
+	return types.ContainerCreateResult{ID: result.ID, Warnings: result.Warnings}, nil
+}
+
+// ContainerStart implements engine.Backend
+func (b *Backend) ContainerStart(ctx context.Context, ID string) error {
+	return b.doJSON(ctx, http.MethodPost, "/containers/"+ID+"/start", nil, nil)
+}
+
+// ContainerStop implements engine.Backend
+func (b *Backend) ContainerStop(ctx context.Context, ID string, timeout time.Duration) error {
+	path := fmt.Sprintf("/containers/%s/stop?t=%d", ID, int(timeout.Seconds()))
+	return b.doJSON(ctx, http.MethodPost, path, nil, nil)
+}
+
+// ContainerRemove implements engine.Backend
+func (b *Backend) ContainerRemove(ctx context.Context, ID string, force bool) error {
+	path := fmt.Sprintf("/containers/%s?force=%s", ID, strconv.FormatBool(force))
+	return b.doJSON(ctx, http.MethodDelete, path, nil, nil)
+}
+
+// ContainerInspect implements engine.Backend
+func (b *Backend) ContainerInspect(ctx context.Context, ID string) (types.ContainerJSON, error) {
+	var raw struct {
+		ID    string `json:"Id"`
+		Image string `json:"Image"`
+		State struct {
+			Running bool `json:"Running"`
+		} `json:"State"`
+		Config struct {
+			Labels map[string]string `json:"Labels"`
+		} `json:"Config"`
+		NetworkSettings struct {
+			Networks map[string]struct {
+				IPAddress string `json:"IPAddress"`
+			} `json:"Networks"`
+		} `json:"NetworkSettings"`
+	}
+	if err := b.doJSON(ctx, http.MethodGet, "/containers/"+ID+"/json", nil, &raw); err != nil {
+		return types.ContainerJSON{}, err
+	}
+
+	networks := map[string]string{}
+	for name, n := range raw.NetworkSettings.Networks {
+		networks[name] = n.IPAddress
+	}
+
+	return types.ContainerJSON{
+		ID:       raw.ID,
+		Image:    raw.Image,
+		Running:  raw.State.Running,
+		Networks: networks,
+		Labels:   raw.Config.Labels,
+	}, nil
+}
+
+// ContainerList implements engine.Backend
+func (b *Backend) ContainerList(ctx context.Context, opts types.ContainerListOptions) ([]types.Container, error) {
+	path := fmt.Sprintf("/containers/json?all=%s", strconv.FormatBool(opts.All))
+	var raw []struct {
+		ID     string            `json:"Id"`
+		Names  []string          `json:"Names"`
+		Image  string            `json:"Image"`
+		Labels map[string]string `json:"Labels"`
+	}
+	if err := b.doJSON(ctx, http.MethodGet, path, nil, &raw); err != nil {
+		return nil, err
+	}
+
+	result := make([]types.Container, 0, len(raw))
+	for _, c := range raw {
+		result = append(result, types.Container{
+			ID:     c.ID,
+			Names:  c.Names,
+			Image:  c.Image,
+			Labels: c.Labels,
+		})
+	}
+	return result, nil
+}
+
+// ImagePull implements engine.Backend
+func (b *Backend) ImagePull(ctx context.Context, image string) (io.ReadCloser, error) {
+	path := "/images/create?fromImage=" + url.QueryEscape(image)
+	resp, err := b.do(ctx, http.MethodPost, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// ImageBuild implements engine.Backend
+func (b *Backend) ImageBuild(ctx context.Context, tar io.Reader, name string) (io.ReadCloser, error) {
+	path := "/build?t=" + url.QueryEscape(name)
+	resp, err := b.do(ctx, http.MethodPost, path, tar)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// ContainerLogs implements engine.Backend
+func (b *Backend) ContainerLogs(ctx context.Context, ID string, opts types.LogsOptions) (io.ReadCloser, error) {
+	path := fmt.Sprintf("/containers/%s/logs?follow=%s&tail=%s&stdout=%s&stderr=%s&since=%s",
+		ID, strconv.FormatBool(opts.Follow), url.QueryEscape(opts.Tail),
+		strconv.FormatBool(opts.ShowStdout), strconv.FormatBool(opts.ShowStderr),
+		url.QueryEscape(opts.Since))
+	resp, err := b.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// ContainerStats implements engine.Backend
+func (b *Backend) ContainerStats(ctx context.Context, ID string) (io.ReadCloser, error) {
+	resp, err := b.do(ctx, http.MethodGet, "/containers/"+ID+"/stats?stream=true", nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// ContainerEvents implements engine.Backend
+func (b *Backend) ContainerEvents(ctx context.Context, opts types.EventsOptions) (io.ReadCloser, error) {
+	q := url.Values{}
+	q.Set("since", opts.Since)
+	q.Set("until", opts.Until)
+
+	filters := map[string][]string{}
+	if opts.Type != "" {
+		filters["type"] = []string{opts.Type}
+	}
+	if opts.Event != "" {
+		filters["event"] = []string{opts.Event}
+	}
+	for k, v := range opts.Labels {
+		filters["label"] = append(filters["label"], k+"="+v)
+	}
+	if len(filters) > 0 {
+		raw, err := json.Marshal(filters)
+		if err != nil {
+			return nil, err
+		}
+		q.Set("filters", string(raw))
+	}
+
+	resp, err := b.do(ctx, http.MethodGet, "/events?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Exec implements engine.Backend
+func (b *Backend) Exec(ctx context.Context, ID string, opts types.ExecConfig) (types.ExecResult, error) {
+	payload := map[string]interface{}{
+		"Cmd":          opts.Cmd,
+		"Env":          opts.Env,
+		"Privileged":   opts.Privileged,
+		"AttachStdin":  opts.AttachStdin,
+		"AttachStdout": opts.AttachStdout,
+		"AttachStderr": opts.AttachStderr,
+	}
+
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := b.doJSON(ctx, http.MethodPost, "/containers/"+ID+"/exec", payload, &created); err != nil {
+		return types.ExecResult{}, err
+	}
+
+	conn, err := b.hijack(ctx, http.MethodPost, "/exec/"+created.ID+"/start", bytes.NewReader([]byte(`{"Detach":false,"Tty":false}`)))
+	if err != nil {
+		return types.ExecResult{}, err
+	}
+
+	return types.ExecResult{ExecID: created.ID, Conn: conn}, nil
+}
+
+// hijack dials its own unix socket connection and takes over the stream
+// after the HTTP handshake, the way docker's engine-api client does for exec
+// attach. b.httpClient can't be reused here: a connection pulled from an
+// *http.Client's pool is a plain http.Response.Body, never a raw,
+// full-duplex io.ReadWriteCloser, no matter the transport
+func (b *Backend) hijack(ctx context.Context, method, path string, body io.Reader) (io.ReadWriteCloser, error) {
+	conn, err := net.Dial("unix", b.sockPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, b.base+path, body)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	clientconn := httputil.NewClientConn(conn, nil)
+	resp, err := clientconn.Do(req)
+	if err != nil && err != httputil.ErrPersistEOF {
+		clientconn.Close()
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer clientconn.Close()
+		msg, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Podman API %s %s: %s: %s", method, path, resp.Status, string(msg))
+	}
+
+	rwc, br := clientconn.Hijack()
+	return &hijackedConn{conn: rwc, br: br}, nil
+}
+
+// hijackedConn is a raw, full-duplex connection handed back by hijack, with
+// any bytes httputil's ClientConn already buffered past the HTTP response
+// headers prepended to the read side
+type hijackedConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func (h *hijackedConn) Read(p []byte) (int, error) { return h.br.Read(p) }
+
+func (h *hijackedConn) Write(p []byte) (int, error) { return h.conn.Write(p) }
+
+func (h *hijackedConn) Close() error { return h.conn.Close() }
+
+// CopyToContainer implements engine.Backend
+func (b *Backend) CopyToContainer(ctx context.Context, ID, path string, content io.Reader) error {
+	target := fmt.Sprintf("/containers/%s/archive?path=%s", ID, url.QueryEscape(path))
+	resp, err := b.do(ctx, http.MethodPut, target, content)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// CopyFromContainer implements engine.Backend
+func (b *Backend) CopyFromContainer(ctx context.Context, ID, path string) (io.ReadCloser, string, error) {
+	target := fmt.Sprintf("/containers/%s/archive?path=%s", ID, url.QueryEscape(path))
+	resp, err := b.do(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	return resp.Body, strings.TrimPrefix(path, "/"), nil
+}
+
+// Close implements engine.Backend
+func (b *Backend) Close() error {
+	b.httpClient.CloseIdleConnections()
+	return nil
+}
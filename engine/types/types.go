@@ -0,0 +1,94 @@
+package types
+
+import "io"
+
+// Info is a minimal, backend-agnostic summary of a node's container engine
+// analogous to docker's `/info` endpoint, trimmed to the fields core actually
+// consumes
+type Info struct {
+	ID            string
+	Driver        string
+	NCPU          int
+	MemTotal      int64
+	ServerVersion string
+}
+
+// ContainerJSON is a backend-agnostic container inspect result
+type ContainerJSON struct {
+	ID       string
+	Image    string
+	Running  bool
+	Networks map[string]string
+	Labels   map[string]string
+}
+
+// Container is a single entry of a container list
+type Container struct {
+	ID     string
+	Names  []string
+	Image  string
+	Labels map[string]string
+}
+
+// ContainerCreateOptions describes how to create a container, independent of
+// which engine backend ends up fulfilling the request
+type ContainerCreateOptions struct {
+	Name       string
+	Image      string
+	Cmd        []string
+	Env        []string
+	Labels     map[string]string
+	CPUQuota   int64
+	Memory     int64
+	Privileged bool
+	Volumes    []string
+	Networks   []string
+}
+
+// ContainerCreateResult is what a backend returns after creating a container
+type ContainerCreateResult struct {
+	ID       string
+	Warnings []string
+}
+
+// ContainerListOptions filters ContainerList
+type ContainerListOptions struct {
+	All    bool
+	Labels map[string]string
+}
+
+// LogsOptions controls ContainerLogs
+type LogsOptions struct {
+	Follow     bool
+	Since      string
+	Tail       string
+	ShowStdout bool
+	ShowStderr bool
+}
+
+// EventsOptions filters ContainerEvents, mirroring docker's own /events
+// query parameters well enough for cluster to narrow a node's event stream
+// before it ever leaves the engine backend
+type EventsOptions struct {
+	Since  string
+	Until  string
+	Type   string
+	Event  string
+	Labels map[string]string
+}
+
+// ExecConfig describes a one-off exec inside a container
+type ExecConfig struct {
+	Cmd          []string
+	Env          []string
+	Privileged   bool
+	AttachStdin  bool
+	AttachStdout bool
+	AttachStderr bool
+}
+
+// ExecResult is the handle to a running exec, its combined output stream
+type ExecResult struct {
+	ExecID string
+	Conn   io.ReadWriteCloser
+}
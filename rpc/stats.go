@@ -0,0 +1,62 @@
+package rpc
+
+import (
+	pb "github.com/projecteru2/core/rpc/gen"
+	log "github.com/sirupsen/logrus"
+)
+
+// ContainerStats streams resource usage samples for the given container
+// IDs. The cluster layer multiplexes each node's engine stats channel,
+// decimates to its configured sample interval and merges the result into a
+// single stream in arrival order, each sample tagged with its node's name,
+// so dashboards don't have to poll Inspect
+func (v *Vibranium) ContainerStats(opts *pb.ContainerIDs, stream pb.CoreRPC_ContainerStatsServer) error {
+	ctx := stream.Context()
+
+	ch, err := v.cluster.ContainerStats(ctx, opts.Ids)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(toRPCStatsMessage(msg)); err != nil {
+				log.Errorf("[ContainerStats] send failed: %v", err)
+				return err
+			}
+		}
+	}
+}
+
+// ContainerEvents streams container/image/network/volume lifecycle events
+// matching opts' type/event/label filters, so clients can react to things
+// like health-status changes in real time instead of polling
+func (v *Vibranium) ContainerEvents(opts *pb.EventFilter, stream pb.CoreRPC_ContainerEventsServer) error {
+	ctx := stream.Context()
+
+	ch, err := v.cluster.ContainerEvents(ctx, toCoreEventFilter(opts))
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(toRPCEventMessage(msg)); err != nil {
+				log.Errorf("[ContainerEvents] send failed: %v", err)
+				return err
+			}
+		}
+	}
+}
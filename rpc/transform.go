@@ -43,11 +43,17 @@ func toRPCNetwork(n *enginetypes.Network) *pb.Network {
 
 func toRPCNode(ctx context.Context, n *types.Node) *pb.Node {
 	var nodeInfo string
-	if info, err := n.Info(ctx); err == nil {
-		bytes, _ := json.Marshal(info)
-		nodeInfo = string(bytes)
-	} else {
-		nodeInfo = err.Error()
+	// WatchPodNodes delivers nodes built straight from an etcd watch event
+	// (json.Unmarshal, or a bare Name/Podname for a removal); neither path
+	// dials the node, so there's no engine to ask and n.Info(ctx) would
+	// panic on the nil interface
+	if n.Engine != nil {
+		if info, err := n.Info(ctx); err == nil {
+			bytes, _ := json.Marshal(info)
+			nodeInfo = string(bytes)
+		} else {
+			nodeInfo = err.Error()
+		}
 	}
 
 	return &pb.Node{
@@ -409,6 +415,48 @@ func toRPCLogStreamMessage(msg *types.LogStreamMessage) *pb.LogStreamMessage {
 	return r
 }
 
+func toRPCStatsMessage(msg *types.StatsMessage) *pb.StatsMessage {
+	r := &pb.StatsMessage{
+		Id:          msg.ID,
+		Nodename:    msg.Nodename,
+		CpuPercent:  msg.CPUPercent,
+		MemoryUsage: msg.MemoryUsage,
+		MemoryLimit: msg.MemoryLimit,
+		NetworkRx:   msg.NetworkRx,
+		NetworkTx:   msg.NetworkTx,
+		BlockRead:   msg.BlockRead,
+		BlockWrite:  msg.BlockWrite,
+		PidsCurrent: msg.PidsCurrent,
+	}
+	if msg.Error != nil {
+		r.Error = msg.Error.Error()
+	}
+	return r
+}
+
+func toRPCEventMessage(msg *types.EventMessage) *pb.EventMessage {
+	r := &pb.EventMessage{
+		Type:     msg.Type,
+		Action:   msg.Action,
+		Id:       msg.ID,
+		Nodename: msg.Nodename,
+		Time:     msg.Time,
+		Labels:   msg.Labels,
+	}
+	if msg.Error != nil {
+		r.Error = msg.Error.Error()
+	}
+	return r
+}
+
+func toCoreEventFilter(f *pb.EventFilter) types.EventFilter {
+	return types.EventFilter{
+		Type:   f.Type,
+		Event:  f.Event,
+		Labels: f.Labels,
+	}
+}
+
 func makeTempTarFiles(data map[string][]byte) (map[string]string, error) {
 	tarFiles := map[string]string{}
 	for path, data := range data {
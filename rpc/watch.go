@@ -0,0 +1,38 @@
+package rpc
+
+import (
+	pb "github.com/projecteru2/core/rpc/gen"
+	log "github.com/sirupsen/logrus"
+)
+
+// WatchPodNodes streams a pod's node topology changes - additions,
+// removals, updates and CPU rebalances - as they happen, so dashboards and
+// other long-lived clients don't have to poll ListPodNodes
+func (v *Vibranium) WatchPodNodes(opts *pb.WatchPodNodesOptions, stream pb.CoreRPC_WatchPodNodesServer) error {
+	ctx := stream.Context()
+
+	events, err := v.cluster.WatchPodNodes(ctx, opts.Podname)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+
+			msg := &pb.NodeEvent{
+				Type: string(event.Type),
+				Node: toRPCNode(ctx, event.Node),
+			}
+			if err := stream.Send(msg); err != nil {
+				log.Errorf("[WatchPodNodes] send event for %q failed: %v", opts.Podname, err)
+				return err
+			}
+		}
+	}
+}
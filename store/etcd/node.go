@@ -4,18 +4,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
-	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
-	"strings"
-	"sync"
-	"time"
 
 	log "github.com/Sirupsen/logrus"
-	engineapi "github.com/docker/engine-api/client"
-	"github.com/docker/go-connections/tlsconfig"
+	"gitlab.ricebook.net/platform/core/engine"
+	_ "gitlab.ricebook.net/platform/core/engine/docker"
+	_ "gitlab.ricebook.net/platform/core/engine/podman"
 	"gitlab.ricebook.net/platform/core/types"
 	"gitlab.ricebook.net/platform/core/utils"
 	"golang.org/x/net/context"
@@ -41,21 +38,23 @@ func (k *krypton) GetNode(podname, nodename string) (*types.Node, error) {
 		return nil, err
 	}
 
-	engine, err := k.makeDockerClient(podname, nodename, node.Endpoint, false)
+	backend, err := k.makeEngineClient(podname, nodename, node.Endpoint, false)
 	if err != nil {
 		return nil, err
 	}
 
-	node.Engine = engine
+	node.Engine = backend
 	return node, nil
 }
 
 // add a node
 // save it to etcd
 // storage path in etcd is `/eru-core/pod/:podname/node/:nodename/info`
+// endpoint decides which engine backend the node is dialed through by its
+// scheme, e.g. `tcp://` / `unix://` for docker, `podman+unix://` for podman
 func (k *krypton) AddNode(name, endpoint, podname, cafile, certfile, keyfile string, public bool) (*types.Node, error) {
-	if !strings.HasPrefix(endpoint, "tcp://") {
-		return nil, fmt.Errorf("Endpoint must starts with tcp:// %q", endpoint)
+	if !engine.Supports(endpoint) {
+		return nil, fmt.Errorf("Endpoint %q has no registered engine backend", endpoint)
 	}
 
 	_, err := k.GetPod(podname)
@@ -79,13 +78,13 @@ func (k *krypton) AddNode(name, endpoint, podname, cafile, certfile, keyfile str
 		}
 	}
 
-	// 尝试加载docker的客户端
-	engine, err := k.makeDockerClient(podname, name, endpoint, false)
+	// 尝试加载引擎客户端, 根据endpoint的scheme选择后端
+	backend, err := k.makeEngineClient(podname, name, endpoint, false)
 	if err != nil {
 		return nil, err
 	}
 
-	info, err := engine.Info(context.Background())
+	info, err := backend.Info(context.Background())
 	if err != nil {
 		return nil, err
 	}
@@ -102,7 +101,7 @@ func (k *krypton) AddNode(name, endpoint, podname, cafile, certfile, keyfile str
 		Podname:  podname,
 		Public:   public,
 		CPU:      cpumap,
-		Engine:   engine,
+		Engine:   backend,
 	}
 
 	bytes, err := json.Marshal(node)
@@ -165,6 +164,14 @@ func (k *krypton) GetNodesByPod(podname string) ([]*types.Node, error) {
 		if err != nil {
 			return nodes, err
 		}
+
+		// consult the cached health instead of paying a synchronous Info()
+		// RTT per node; GetNode above has already dialed or reused the
+		// node's client, and a freshly dialed entry reports healthy until
+		// its first background probe says otherwise
+		healthy, _ := k.NodeHealth(podname, nodename)
+		n.Available = healthy
+
 		nodes = append(nodes, n)
 	}
 	return nodes, err
@@ -233,56 +240,17 @@ func (k *krypton) UpdateNodeCPU(podname, nodename string, cpu types.CPUMap, acti
 	return nil
 }
 
-// cache connections
-// otherwise they'll leak
-type cache struct {
-	sync.Mutex
-	clients map[string]*engineapi.Client
-}
-
-func (c cache) set(host string, client *engineapi.Client) {
-	c.Lock()
-	defer c.Unlock()
-
-	c.clients[host] = client
-}
-
-func (c cache) get(host string) *engineapi.Client {
-	c.Lock()
-	defer c.Unlock()
-	return c.clients[host]
-}
-
-var _cache = cache{clients: make(map[string]*engineapi.Client)}
-
-// use endpoint, cert files path, and api version to create docker client
-// we don't check whether this is connectable
-func makeRawClient(endpoint, certpath, apiversion string) (*engineapi.Client, error) {
-	var cli *http.Client
-	if certpath != "" {
-		options := tlsconfig.Options{
-			CAFile:             filepath.Join(certpath, "ca.pem"),
-			CertFile:           filepath.Join(certpath, "cert.pem"),
-			KeyFile:            filepath.Join(certpath, "key.pem"),
-			InsecureSkipVerify: false,
-		}
-		tlsc, err := tlsconfig.Client(options)
-		if err != nil {
-			return nil, err
-		}
-
-		cli = &http.Client{
-			Transport: &http.Transport{
-				TLSClientConfig: tlsc,
-			},
-		}
+// makeEngineClient dispatches to the engine backend registered for
+// endpoint's scheme (docker for tcp://, unix://, podman for podman+unix://),
+// keyed in the cache by podname/nodename rather than host so rotating a
+// node's endpoint doesn't orphan its old cache entry. The synchronous
+// liveness probe that used to live here has moved to a background ticker
+// in engineCache; see NodeHealth
+func (k *krypton) makeEngineClient(podname, nodename, endpoint string, force bool) (engine.Backend, error) {
+	if client := k.engineCache().get(podname, nodename); client != nil && !force {
+		return client, nil
 	}
 
-	log.Debugf("Create new http.Client for %q, %q, %q", endpoint, certpath, apiversion)
-	return engineapi.NewClient(endpoint, apiversion, cli, nil)
-}
-
-func (k *krypton) makeDockerClient(podname, nodename, endpoint string, force bool) (*engineapi.Client, error) {
 	u, err := url.Parse(endpoint)
 	if err != nil {
 		return nil, err
@@ -290,45 +258,49 @@ func (k *krypton) makeDockerClient(podname, nodename, endpoint string, force boo
 
 	host, _, err := net.SplitHostPort(u.Host)
 	if err != nil {
-		return nil, err
-	}
-
-	// try get client, if nil, create a new one
-	client := _cache.get(host)
-	if client == nil || force {
-		dockerCertPath := ""
-		// 如果设置了cert path说明需要用tls来连接
-		// 那么先检查有没有这些证书, 没有的话要从etcd里dump到本地
-		if k.config.Docker.CertPath != "" {
-			dockerCertPath = filepath.Join(k.config.Docker.CertPath, host)
-			_, err = os.Stat(dockerCertPath)
-			// 没有证书, 从etcd里dump
-			if os.IsNotExist(err) {
-				if err := k.dumpFromEtcd(podname, nodename, dockerCertPath); err != nil {
-					return nil, err
-				}
+		host = u.Host
+	}
+	if host == "" {
+		host = u.Path
+	}
+
+	certPath := ""
+	// 如果设置了cert path说明需要用tls来连接
+	// 那么先检查有没有这些证书, 没有的话要从etcd里dump到本地
+	// CertPath only ever applies to docker's tcp/unix endpoints - a Podman
+	// node (podman+unix://) never had ca/cert/key dumped to etcd for it in
+	// the first place, so running this branch for one errors out on etcd
+	// keys that were never written, breaking pods that mix both backends
+	if k.config.Docker.CertPath != "" && usesDockerTLS(u.Scheme) {
+		certPath = filepath.Join(k.config.Docker.CertPath, host)
+		_, err = os.Stat(certPath)
+		// 没有证书, 从etcd里dump
+		if os.IsNotExist(err) {
+			if err := k.dumpFromEtcd(podname, nodename, certPath); err != nil {
+				return nil, err
 			}
 		}
-
-		client, err = makeRawClient(endpoint, dockerCertPath, k.config.Docker.APIVersion)
-		if err != nil {
-			return nil, err
-		}
-
-		_cache.set(host, client)
 	}
 
-	// timeout in 5 seconds
-	// timeout means node is not available
-	ctx, _ := context.WithTimeout(context.Background(), 5*time.Second)
-	_, err = client.Info(ctx)
+	client, err := engine.Open(endpoint, engine.Config{CertPath: certPath, APIVersion: k.config.Docker.APIVersion})
 	if err != nil {
+		dialFailures.Inc()
 		return nil, err
 	}
 
+	entry := k.engineCache().set(podname, nodename, client)
+	k.watchNodeCerts(entry)
+
 	return client, nil
 }
 
+// usesDockerTLS reports whether scheme is one of docker's own endpoint
+// schemes (as opposed to podman+unix, or any future non-docker backend),
+// so only docker nodes ever go through CertPath's dump-from-etcd branch
+func usesDockerTLS(scheme string) bool {
+	return scheme == "tcp" || scheme == "unix"
+}
+
 // dump certificated files from etcd to local file system
 func (k *krypton) dumpFromEtcd(podname, nodename, certprefix string) error {
 	// create necessary directory
@@ -0,0 +1,309 @@
+package etcdstore
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/prometheus/client_golang/prometheus"
+	"gitlab.ricebook.net/platform/core/engine"
+	"golang.org/x/net/context"
+)
+
+const (
+	// defaultEngineCacheMaxSize bounds how many dialed engine backends we
+	// hold open at once when config.Docker.EngineCacheSize isn't set; the
+	// oldest idle entry is evicted to make room for a new one
+	defaultEngineCacheMaxSize = 512
+	// defaultEngineCacheIdleTTL closes a cached backend that hasn't been
+	// touched in this long when config.Docker.EngineCacheIdleTTL isn't set,
+	// instead of keeping it open forever
+	defaultEngineCacheIdleTTL = 10 * time.Minute
+	// engineHealthInterval is how often the background ticker re-probes a
+	// cached backend's Info(), replacing the old synchronous 5s check
+	engineHealthInterval = 5 * time.Second
+)
+
+var (
+	cacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "eru_core",
+		Subsystem: "engine_cache",
+		Name:      "hits_total",
+		Help:      "Number of engine client cache hits",
+	})
+	cacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "eru_core",
+		Subsystem: "engine_cache",
+		Name:      "misses_total",
+		Help:      "Number of engine client cache misses",
+	})
+	cacheEvictions = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "eru_core",
+		Subsystem: "engine_cache",
+		Name:      "evictions_total",
+		Help:      "Number of engine clients evicted from the cache",
+	})
+	dialFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "eru_core",
+		Subsystem: "engine_cache",
+		Name:      "dial_failures_total",
+		Help:      "Number of failed attempts to dial an engine backend",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(cacheHits, cacheMisses, cacheEvictions, dialFailures)
+}
+
+// engineCacheEntry wraps one cached backend along with its last-known
+// health, so GetNodesByPod can read it without paying a synchronous RTT
+type engineCacheEntry struct {
+	key      string
+	podname  string
+	nodename string
+	backend  engine.Backend
+	lastUsed time.Time
+
+	healthMu  sync.RWMutex
+	healthy   bool
+	healthErr error
+
+	stop    chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	lruElem *list.Element
+}
+
+func (e *engineCacheEntry) touch() {
+	e.lastUsed = time.Now()
+}
+
+func (e *engineCacheEntry) setHealth(healthy bool, err error) {
+	e.healthMu.Lock()
+	defer e.healthMu.Unlock()
+	e.healthy = healthy
+	e.healthErr = err
+}
+
+func (e *engineCacheEntry) health() (bool, error) {
+	e.healthMu.RLock()
+	defer e.healthMu.RUnlock()
+	return e.healthy, e.healthErr
+}
+
+// engineCache is a bounded, TTL-based cache of dialed engine backends, keyed
+// by `podname/nodename`. It evicts the least-recently-used entry once full,
+// reaps entries left idle longer than ttl, and probes each entry's health
+// on a background ticker instead of on every call
+type engineCache struct {
+	sync.Mutex
+	entries map[string]*engineCacheEntry
+	lru     *list.List // front = most recently used
+	maxSize int
+	ttl     time.Duration
+}
+
+func newEngineCache(maxSize int, ttl time.Duration) *engineCache {
+	c := &engineCache{
+		entries: make(map[string]*engineCacheEntry),
+		lru:     list.New(),
+		maxSize: maxSize,
+		ttl:     ttl,
+	}
+	go c.reapLoop()
+	return c
+}
+
+func cacheKey(podname, nodename string) string {
+	return fmt.Sprintf("%s/%s", podname, nodename)
+}
+
+// get returns the cached backend for podname/nodename, or nil if absent
+func (c *engineCache) get(podname, nodename string) engine.Backend {
+	c.Lock()
+	defer c.Unlock()
+
+	key := cacheKey(podname, nodename)
+	entry, ok := c.entries[key]
+	if !ok {
+		cacheMisses.Inc()
+		return nil
+	}
+
+	cacheHits.Inc()
+	entry.touch()
+	c.lru.MoveToFront(entry.lruElem)
+	return entry.backend
+}
+
+// set installs backend for podname/nodename, evicting the LRU entry first
+// if the cache is already at capacity, and starts its health ticker
+func (c *engineCache) set(podname, nodename string, backend engine.Backend) *engineCacheEntry {
+	c.Lock()
+	defer c.Unlock()
+
+	key := cacheKey(podname, nodename)
+	if old, ok := c.entries[key]; ok {
+		c.removeLocked(old)
+	}
+
+	for len(c.entries) >= c.maxSize {
+		oldest := c.lru.Back()
+		if oldest == nil {
+			break
+		}
+		c.removeLocked(oldest.Value.(*engineCacheEntry))
+		cacheEvictions.Inc()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	entry := &engineCacheEntry{
+		key:      key,
+		podname:  podname,
+		nodename: nodename,
+		backend:  backend,
+		lastUsed: time.Now(),
+		healthy:  true,
+		stop:     make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	entry.lruElem = c.lru.PushFront(entry)
+	c.entries[key] = entry
+
+	go entry.runHealthLoop()
+	return entry
+}
+
+// evict drops podname/nodename from the cache, if present, closing its
+// backend. Used both by idle reaping and by cert-rotation invalidation
+func (c *engineCache) evict(podname, nodename string) {
+	c.Lock()
+	defer c.Unlock()
+
+	key := cacheKey(podname, nodename)
+	entry, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	c.removeLocked(entry)
+	cacheEvictions.Inc()
+}
+
+// removeLocked must be called with c locked
+func (c *engineCache) removeLocked(entry *engineCacheEntry) {
+	delete(c.entries, entry.key)
+	c.lru.Remove(entry.lruElem)
+	close(entry.stop)
+	entry.cancel()
+	if err := entry.backend.Close(); err != nil {
+		log.Errorf("[engineCache] close backend for %q failed: %v", entry.key, err)
+	}
+}
+
+// reapLoop periodically evicts entries idle past c.ttl
+func (c *engineCache) reapLoop() {
+	ticker := time.NewTicker(c.ttl / 2)
+	defer ticker.Stop()
+	for range ticker.C {
+		c.Lock()
+		var idle []*engineCacheEntry
+		for _, entry := range c.entries {
+			if time.Since(entry.lastUsed) > c.ttl {
+				idle = append(idle, entry)
+			}
+		}
+		for _, entry := range idle {
+			c.removeLocked(entry)
+			cacheEvictions.Inc()
+		}
+		c.Unlock()
+	}
+}
+
+// runHealthLoop asynchronously re-probes Info() on a ticker so callers never
+// pay a synchronous RTT just to read an entry's health
+func (e *engineCacheEntry) runHealthLoop() {
+	ticker := time.NewTicker(engineHealthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), engineHealthInterval)
+			_, err := e.backend.Info(ctx)
+			cancel()
+			e.setHealth(err == nil, err)
+		}
+	}
+}
+
+var (
+	_cache     *engineCache
+	_cacheOnce sync.Once
+)
+
+// engineCache returns the process-wide engine cache, sized from
+// k.config.Docker the first time any krypton touches it. It's built lazily,
+// rather than at package init, because the size/TTL an operator configures
+// isn't known until a krypton carrying that config exists
+func (k *krypton) engineCache() *engineCache {
+	_cacheOnce.Do(func() {
+		maxSize := k.config.Docker.EngineCacheSize
+		if maxSize <= 0 {
+			maxSize = defaultEngineCacheMaxSize
+		}
+		ttl := k.config.Docker.EngineCacheIdleTTL
+		if ttl <= 0 {
+			ttl = defaultEngineCacheIdleTTL
+		}
+		_cache = newEngineCache(maxSize, ttl)
+	})
+	return _cache
+}
+
+// NodeHealth reports the last-known health of podname/nodename's cached
+// engine backend without dialing it. GetNodesByPod consults this instead of
+// paying a synchronous Info() round-trip per node; nodes never dialed yet
+// report unhealthy until their first makeEngineClient call populates the cache
+func (k *krypton) NodeHealth(podname, nodename string) (bool, error) {
+	cache := k.engineCache()
+	cache.Lock()
+	entry, ok := cache.entries[cacheKey(podname, nodename)]
+	cache.Unlock()
+	if !ok {
+		return false, fmt.Errorf("No cached engine backend for %s/%s", podname, nodename)
+	}
+	return entry.health()
+}
+
+// watchNodeCerts watches this node's TLS material in etcd and evicts its
+// cached engine backend on any change, so the next makeEngineClient call
+// re-dials and re-dumps the rotated certs instead of reusing a stale client.
+// It's started once per cache entry, the first time that node's client is
+// cached, and its goroutines exit as soon as entry is evicted (entry.ctx is
+// canceled) rather than outliving it and piling up on every re-dial
+func (k *krypton) watchNodeCerts(entry *engineCacheEntry) {
+	for _, keyFormat := range []string{nodeCaKey, nodeCertKey, nodeKeyKey} {
+		go k.watchCertKey(fmt.Sprintf(keyFormat, entry.podname, entry.nodename), entry)
+	}
+}
+
+func (k *krypton) watchCertKey(key string, entry *engineCacheEntry) {
+	watcher := k.etcd.Watcher(key, nil)
+	for {
+		if _, err := watcher.Next(entry.ctx); err != nil {
+			if entry.ctx.Err() != nil {
+				return
+			}
+			log.Errorf("[watchCertKey] watch %q failed: %v", key, err)
+			return
+		}
+		log.Infof("[watchCertKey] %q rotated, evicting cached client for %s/%s", key, entry.podname, entry.nodename)
+		k.engineCache().evict(entry.podname, entry.nodename)
+		return
+	}
+}
@@ -0,0 +1,66 @@
+package etcdstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	etcdclient "github.com/coreos/etcd/client"
+	"gitlab.ricebook.net/platform/core/types"
+	"golang.org/x/net/context"
+)
+
+// RegisterSelf is the opt-in node self-registration entry point: instead of
+// an operator calling AddNode, a node agent with its own etcd credentials
+// PUTs its own nodeInfoKey directly, carrying a TTL lease. It's exported so
+// it can be called from outside this package, by a small agent binary
+// running on the node itself rather than by core
+func RegisterSelf(etcdCli etcdclient.KeysAPI, podname string, node *types.Node, ttl time.Duration) error {
+	bytes, err := json.Marshal(node)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf(nodeInfoKey, podname, node.Name)
+	_, err = etcdCli.Set(context.Background(), key, string(bytes), &etcdclient.SetOptions{TTL: ttl})
+	return err
+}
+
+// refreshSelf extends node's TTL lease without rewriting its value. Passing
+// Refresh (and no value, per etcd's own requirement) keeps it from emitting
+// a "set" event on every heartbeat, which watchPodLoop would otherwise turn
+// into a bogus NodeUpdated on every tick even though nothing changed
+func refreshSelf(etcdCli etcdclient.KeysAPI, podname string, node *types.Node, ttl time.Duration) error {
+	key := fmt.Sprintf(nodeInfoKey, podname, node.Name)
+	_, err := etcdCli.Set(context.Background(), key, "", &etcdclient.SetOptions{
+		TTL:       ttl,
+		Refresh:   true,
+		PrevExist: etcdclient.PrevExist,
+	})
+	return err
+}
+
+// KeepAliveSelf refreshes node's lease on a ticker at ttl/3 until ctx is
+// canceled, well before the TTL can lapse. If the agent dies or loses
+// network to etcd, the key's TTL eventually lapses on its own and WatchPod
+// observes it as a NodeRemoved event, so the scheduler stops placing
+// containers there without any operator action
+func KeepAliveSelf(ctx context.Context, etcdCli etcdclient.KeysAPI, podname string, node *types.Node, ttl time.Duration) {
+	if err := RegisterSelf(etcdCli, podname, node, ttl); err != nil {
+		log.Errorf("[KeepAliveSelf] initial registration of %q failed: %v", node.Name, err)
+	}
+
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := refreshSelf(etcdCli, podname, node, ttl); err != nil {
+				log.Errorf("[KeepAliveSelf] refresh of %q failed: %v", node.Name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package etcdstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	etcdclient "github.com/coreos/etcd/client"
+	"gitlab.ricebook.net/platform/core/types"
+	"golang.org/x/net/context"
+)
+
+// NodeEventType enumerates the kinds of topology changes WatchPod emits
+type NodeEventType string
+
+const (
+	// NodeAdded fires when a node's info document is created
+	NodeAdded NodeEventType = "NodeAdded"
+	// NodeRemoved fires when a node's info document is deleted, either by
+	// an operator or by a self-registration TTL lapsing
+	NodeRemoved NodeEventType = "NodeRemoved"
+	// NodeUpdated fires on any other change to a node's info document
+	NodeUpdated NodeEventType = "NodeUpdated"
+	// CPUChanged fires when an update carries CPU shares that differ from
+	// the ones this watch last saw for the node, as UpdateNodeCPU's writes
+	// do; other fields may have changed in the same write. A node's first
+	// update after the watch starts is reported as NodeUpdated, since there
+	// is no earlier value to compare its CPU shares with
+	CPUChanged NodeEventType = "CPUChanged"
+)
+
+// NodeEvent is a single change to a pod's node membership, as observed by
+// WatchPod. Node is nil's zero value filled in with at least Name/Podname
+// for NodeRemoved, since etcd doesn't give us the deleted value back
+type NodeEvent struct {
+	Type NodeEventType
+	Node *types.Node
+}
+
+// WatchPod streams NodeAdded/NodeRemoved/NodeUpdated/CPUChanged events for
+// podname by watching its etcd subtree, so callers that need live topology
+// (the scheduler, dashboards) don't have to poll GetNodesByPod. The returned
+// channel is closed once ctx is canceled or the underlying watch fails
+func (k *krypton) WatchPod(ctx context.Context, podname string) <-chan NodeEvent {
+	events := make(chan NodeEvent)
+	go k.watchPodLoop(ctx, podname, events)
+	return events
+}
+
+func (k *krypton) watchPodLoop(ctx context.Context, podname string, events chan<- NodeEvent) {
+	defer close(events)
+
+	prefix := fmt.Sprintf(podNodesKey, podname) + "/"
+	watcher := k.etcd.Watcher(fmt.Sprintf(podNodesKey, podname), &etcdclient.WatcherOptions{Recursive: true})
+
+	// track each node's last-seen CPU shares so an update that changes them
+	// (as UpdateNodeCPU does) can be told apart from one that leaves them be
+	lastCPU := map[string]string{}
+
+	for {
+		resp, err := watcher.Next(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Errorf("[watchPodLoop] watch %q failed: %v", podname, err)
+			return
+		}
+
+		// only the node's `info` document matters here; ignore changes to
+		// its ca.pem/cert.pem/key.pem siblings
+		rest := strings.TrimPrefix(resp.Node.Key, prefix)
+		parts := strings.SplitN(rest, "/", 2)
+		if len(parts) != 2 || parts[1] != "info" {
+			continue
+		}
+		nodename := parts[0]
+
+		switch resp.Action {
+		case "delete", "expire":
+			delete(lastCPU, nodename)
+			select {
+			case events <- NodeEvent{Type: NodeRemoved, Node: &types.Node{Name: nodename, Podname: podname}}:
+			case <-ctx.Done():
+				return
+			}
+		case "create", "set", "update", "compareAndSwap":
+			node := &types.Node{}
+			if err := json.Unmarshal([]byte(resp.Node.Value), node); err != nil {
+				log.Errorf("[watchPodLoop] decode %q failed: %v", resp.Node.Key, err)
+				continue
+			}
+
+			eventType := NodeUpdated
+			cpu, err := json.Marshal(node.CPU)
+			if err != nil {
+				log.Errorf("[watchPodLoop] marshal cpu for %q failed: %v", nodename, err)
+				cpu = nil
+			}
+
+			switch {
+			case resp.Action == "create" || resp.PrevNode == nil:
+				eventType = NodeAdded
+			case cpu != nil:
+				if prev, ok := lastCPU[nodename]; ok && prev != string(cpu) {
+					eventType = CPUChanged
+				}
+			}
+			if cpu != nil {
+				lastCPU[nodename] = string(cpu)
+			}
+
+			select {
+			case events <- NodeEvent{Type: eventType, Node: node}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}